@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// defaultPassphraseRetries는 키 패스프레이즈/패스워드/keyboard-interactive
+// 입력을 받는 최대 횟수(첫 시도 포함)다. PKCS#11 PIN은 한 번만 묻는다.
+// 예전 코드는 한 번 실패하면 바로 다음 인증 방법으로 넘어가며 이유도
+// 보여주지 않았다.
+const defaultPassphraseRetries = 3
+
+// AuthChain은 host 설정(PreferredAuthentications, AuthenticationMethods,
+// IdentitiesOnly)에 따라 어떤 인증 방법을 어떤 순서로 시도할지 결정한다.
+type AuthChain struct {
+	host SSHHost
+}
+
+func NewAuthChain(host SSHHost) *AuthChain {
+	return &AuthChain{host: host}
+}
+
+// Methods는 ssh.ClientConfig.Auth에 그대로 넣을 수 있는 목록을 만든다.
+// AuthenticationMethods가 설정되어 있으면 그 순서를 그대로 따르고(서버가 partial
+// success로 다음 방법을 요구하는 "publickey,keyboard-interactive" 같은 조합은
+// go의 ssh 패키지가 이미 처리한다), 아니면 PreferredAuthentications를, 그것도
+// 없으면 기존 기본 순서(publickey, password, keyboard-interactive)를 쓴다.
+func (a *AuthChain) Methods() []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+	for _, name := range a.methodOrder() {
+		method := a.build(name)
+		if method == nil {
+			continue
+		}
+		debugLog("AuthChain: added %q to the auth chain", name)
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+func (a *AuthChain) methodOrder() []string {
+	switch {
+	case a.host.AuthenticationMethods != "":
+		return splitAuthMethodNames(a.host.AuthenticationMethods)
+	case a.host.PreferredAuthentications != "":
+		return splitAuthMethodNames(a.host.PreferredAuthentications)
+	default:
+		return []string{"publickey", "password", "keyboard-interactive"}
+	}
+}
+
+// splitAuthMethodNames는 "publickey,keyboard-interactive" 같은 문자열을
+// 쉼표/공백으로 나눠 중복 없이 순서를 보존한 목록으로 만든다.
+func splitAuthMethodNames(value string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		name := strings.TrimSpace(field)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+func (a *AuthChain) build(name string) ssh.AuthMethod {
+	switch name {
+	case "publickey":
+		return a.publicKeyAuth()
+	case "password":
+		return ssh.RetryableAuthMethod(getPasswordAuth(), defaultPassphraseRetries)
+	case "keyboard-interactive":
+		return ssh.RetryableAuthMethod(getKeyboardInteractiveAuth(), defaultPassphraseRetries)
+	default:
+		debugLog("AuthChain: unknown auth method %q, skipping", name)
+		return nil
+	}
+}
+
+// publicKeyAuth는 IdentityFile, PKCS#11 토큰, SSH Agent(SK 키 포함), 기본 키
+// 파일들을 순서대로 모아 ssh.PublicKeys로 묶는다. 기본 키 파일은 IdentityFile이
+// 없을 때만 쓴다. IdentitiesOnly가 설정되고 IdentityFile도 있으면 SSH Agent는
+// 시도하지 않는다. PKCS#11 토큰의 키는 IdentitiesOnly와 관계없이 포함되고,
+// IdentityFile이 없으면 IdentitiesOnly는 아무 효과가 없다.
+func (a *AuthChain) publicKeyAuth() ssh.AuthMethod {
+	var signers []ssh.Signer
+
+	if a.host.IdentityFile != "" {
+		if _, err := os.Stat(a.host.IdentityFile); err != nil {
+			debugLog("AuthChain: IdentityFile not found: %v", err)
+		} else if signer := loadSignerWithRetries(a.host.IdentityFile, defaultPassphraseRetries); signer != nil {
+			debugLog("AuthChain: loaded signer from IdentityFile %s", a.host.IdentityFile)
+			signers = append(signers, signer)
+		}
+	}
+
+	if a.host.PKCS11Provider != "" {
+		pkcs11Signers, err := loadPKCS11Signers(a.host.PKCS11Provider)
+		if err != nil {
+			debugLog("AuthChain: failed to load PKCS#11 signers from %s: %v", a.host.PKCS11Provider, err)
+		} else {
+			debugLog("AuthChain: loaded %d signer(s) from PKCS#11 provider %s", len(pkcs11Signers), a.host.PKCS11Provider)
+			signers = append(signers, pkcs11Signers...)
+		}
+	}
+
+	if a.host.IdentityFile == "" || !a.host.IdentitiesOnly {
+		if agentSigners := sshAgentSigners(); len(agentSigners) > 0 {
+			debugLog("AuthChain: loaded %d signer(s) from ssh-agent", len(agentSigners))
+			signers = append(signers, agentSigners...)
+		}
+
+		if a.host.IdentityFile == "" {
+			for _, keyPath := range getDefaultKeyPaths() {
+				if _, err := os.Stat(keyPath); err != nil {
+					continue
+				}
+				if signer := loadSignerWithRetries(keyPath, defaultPassphraseRetries); signer != nil {
+					debugLog("AuthChain: loaded signer from default key %s", keyPath)
+					signers = append(signers, signer)
+				}
+			}
+		}
+	}
+
+	if len(signers) == 0 {
+		debugLog("AuthChain: no public key signers available")
+		return nil
+	}
+	return ssh.PublicKeys(signers...)
+}
+
+// loadSignerWithRetries는 keyPath의 개인 키를 읽어 ssh.Signer로 만든다. 암호화된
+// 키라면 패스프레이즈를 최대 maxRetries번(첫 시도 포함) 묻고, 실패할 때마다 이유를
+// debugLog로 남긴다 (예전에는 첫 실패에 조용히 nil을 반환했다).
+func loadSignerWithRetries(keyPath string, maxRetries int) ssh.Signer {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		debugLog("AuthChain: cannot read key %s: %v", keyPath, err)
+		return nil
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer
+	}
+	if !strings.Contains(err.Error(), "passphrase") {
+		debugLog("AuthChain: cannot parse key %s: %v", keyPath, err)
+		return nil
+	}
+
+	// 여러 호스트에 동시 접속할 때(-e ... -j N) 패스프레이즈 프롬프트가 겹치지
+	// 않도록 stdinPromptMu로 직렬화한다.
+	stdinPromptMu.Lock()
+	defer stdinPromptMu.Unlock()
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		fmt.Printf("Enter passphrase for key '%s': ", keyPath)
+		passphrase, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			debugLog("AuthChain: failed to read passphrase for %s: %v", keyPath, err)
+			return nil
+		}
+
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		if err == nil {
+			return signer
+		}
+		debugLog("AuthChain: wrong passphrase for %s (attempt %d/%d): %v", keyPath, attempt, maxRetries, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Too many failed passphrase attempts for %s\n", keyPath)
+	return nil
+}
+
+// sshAgentSigners는 SSH_AUTH_SOCK에 연결된 에이전트가 들고 있는 모든 서명자를
+// 그대로 반환한다. sk-ssh-ed25519@openssh.com 같은 FIDO/SK 키도 에이전트가
+// 중계해 주므로 별도 처리 없이 agent.Signers()만으로 지원된다.
+func sshAgentSigners() []ssh.Signer {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		debugLog("AuthChain: cannot connect to ssh-agent at %s: %v", socket, err)
+		return nil
+	}
+
+	agentClient := agent.NewClient(conn)
+	signers, err := agentClient.Signers()
+	if err != nil {
+		debugLog("AuthChain: ssh-agent returned no signers: %v", err)
+		return nil
+	}
+	return signers
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitAuthMethodNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "comma separated", value: "publickey,keyboard-interactive", expected: []string{"publickey", "keyboard-interactive"}},
+		{name: "space separated", value: "publickey password", expected: []string{"publickey", "password"}},
+		{name: "dedups", value: "publickey,publickey", expected: []string{"publickey"}},
+		{name: "empty", value: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitAuthMethodNames(tt.value)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("splitAuthMethodNames(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthChainMethodOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     SSHHost
+		expected []string
+	}{
+		{
+			name:     "default order",
+			host:     SSHHost{},
+			expected: []string{"publickey", "password", "keyboard-interactive"},
+		},
+		{
+			name:     "preferred authentications",
+			host:     SSHHost{PreferredAuthentications: "publickey,password"},
+			expected: []string{"publickey", "password"},
+		},
+		{
+			name:     "authentication methods takes precedence",
+			host:     SSHHost{PreferredAuthentications: "password", AuthenticationMethods: "publickey,keyboard-interactive"},
+			expected: []string{"publickey", "keyboard-interactive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAuthChain(tt.host).methodOrder()
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("methodOrder() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// A host resolved from a config file with no explicit IdentityFile or
+// PreferredAuthentications must leave those fields empty, not the
+// ssh_config library's built-in OpenSSH defaults (~/.ssh/identity,
+// "gssapi-with-mic,hostbased,publickey,keyboard-interactive,password"). A
+// leaked IdentityFile default disables the default-key fallback in
+// publicKeyAuth, and a leaked PreferredAuthentications default reorders
+// password behind keyboard-interactive and drops it from methodOrder's
+// default branch entirely.
+func TestAuthChainMethodOrderFromResolvedHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config")
+
+	configContent := "Host plain\n    HostName plain.example.com\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v", err)
+	}
+	host := findHost(hosts, "plain")
+	if host == nil {
+		t.Fatal("plain host not found")
+	}
+
+	if host.IdentityFile != "" {
+		t.Errorf("IdentityFile = %q, want empty (no leaked ssh_config default)", host.IdentityFile)
+	}
+	if host.PreferredAuthentications != "" {
+		t.Errorf("PreferredAuthentications = %q, want empty (no leaked ssh_config default)", host.PreferredAuthentications)
+	}
+
+	want := []string{"publickey", "password", "keyboard-interactive"}
+	if got := NewAuthChain(*host).methodOrder(); !reflect.DeepEqual(got, want) {
+		t.Errorf("methodOrder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// runCopyCommand는 "mc cp [-p] <src> <dst>"를 처리한다. src/dst 중 정확히 하나는
+// "host:path" (host를 생략한 ":path"도 허용) 형태의 원격 경로여야 한다.
+func runCopyCommand(args []string) error {
+	fs := flag.NewFlagSet("cp", flag.ExitOnError)
+	preserve := fs.Bool("p", false, "preserve mode and modification time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rest := fs.Args()
+	if len(rest) != 2 {
+		return fmt.Errorf("usage: mc cp [-p] <local> <host:remote> | mc cp [-p] <host:remote> <local>")
+	}
+	src, dst := rest[0], rest[1]
+
+	configPath := getSSHConfigPath()
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("parsing SSH config: %v", err)
+	}
+
+	srcAlias, srcPath, srcRemote := splitHostPath(src)
+	dstAlias, dstPath, dstRemote := splitHostPath(dst)
+
+	switch {
+	case srcRemote && dstRemote:
+		return fmt.Errorf("remote-to-remote copy is not supported")
+	case srcRemote:
+		host, err := resolveCopyHost(srcAlias, hosts)
+		if err != nil {
+			return err
+		}
+		client, sc, err := openSFTP(host, hosts)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		defer sc.Close()
+		return downloadPath(sc, srcPath, dstPath, *preserve)
+	case dstRemote:
+		host, err := resolveCopyHost(dstAlias, hosts)
+		if err != nil {
+			return err
+		}
+		client, sc, err := openSFTP(host, hosts)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		defer sc.Close()
+		return uploadPath(sc, srcPath, dstPath, *preserve)
+	default:
+		return fmt.Errorf("neither path is remote; use host:path for the remote side")
+	}
+}
+
+// splitHostPath는 "host:path" 형식을 분리한다. 콜론 앞부분에 경로 구분자가 있으면
+// (예: "./a:b") host prefix가 아니라 일반 경로로 취급한다. ":path"처럼 host가
+// 비어 있으면 fuzzyfinder로 호스트를 고르라는 신호다.
+func splitHostPath(spec string) (host, path string, isRemote bool) {
+	idx := strings.Index(spec, ":")
+	if idx == -1 {
+		return "", spec, false
+	}
+	if idx > 0 && strings.ContainsAny(spec[:idx], "/\\") {
+		return "", spec, false
+	}
+	return spec[:idx], spec[idx+1:], true
+}
+
+// resolveCopyHost는 alias를 설정된 호스트 목록에서 찾는다. alias가 비어 있으면
+// fuzzyfinder로 고르고, 아니면 resolveProxyJumpHop으로 해석한다: "user@" 접두사를
+// 뗀 이름이 목록에 있으면 그 설정을 쓰고(User만 바꾼다), 없으면 즉석에서
+// SSHHost를 구성한다.
+func resolveCopyHost(alias string, hosts []SSHHost) (SSHHost, error) {
+	if alias == "" {
+		return pickHost(hosts, "")
+	}
+	host, _ := resolveProxyJumpHop(alias, hosts)
+	return host, nil
+}
+
+func openSFTP(host SSHHost, allHosts []SSHHost) (*ssh.Client, *sftp.Client, error) {
+	client, err := openClient(host, allHosts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect: %v", err)
+	}
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to start SFTP session: %v", err)
+	}
+	return client, sc, nil
+}
+
+func uploadPath(sc *sftp.Client, localPath, remotePath string, preserve bool) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("stat %s: %v", localPath, err)
+	}
+	if info.IsDir() {
+		return uploadDir(sc, localPath, remotePath, preserve)
+	}
+	return uploadFile(sc, localPath, remotePath, info, preserve)
+}
+
+func uploadDir(sc *sftp.Client, localDir, remoteDir string, preserve bool) error {
+	if err := sc.MkdirAll(remoteDir); err != nil {
+		return fmt.Errorf("mkdir %s: %v", remoteDir, err)
+	}
+
+	entries, err := os.ReadDir(localDir)
+	if err != nil {
+		return fmt.Errorf("read dir %s: %v", localDir, err)
+	}
+
+	for _, entry := range entries {
+		localChild := filepath.Join(localDir, entry.Name())
+		remoteChild := remoteDir + "/" + entry.Name()
+
+		if entry.IsDir() {
+			if err := uploadDir(sc, localChild, remoteChild, preserve); err != nil {
+				return err
+			}
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := uploadFile(sc, localChild, remoteChild, info, preserve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uploadFile(sc *sftp.Client, localPath, remotePath string, info os.FileInfo, preserve bool) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", localPath, err)
+	}
+	defer src.Close()
+
+	dst, err := sc.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", remotePath, err)
+	}
+	defer dst.Close()
+
+	if err := copyWithProgress(remotePath, dst, src, info.Size()); err != nil {
+		return fmt.Errorf("copy %s: %v", remotePath, err)
+	}
+
+	if preserve {
+		if err := sc.Chmod(remotePath, info.Mode()); err != nil {
+			return fmt.Errorf("chmod %s: %v", remotePath, err)
+		}
+		mtime := info.ModTime()
+		if err := sc.Chtimes(remotePath, mtime, mtime); err != nil {
+			return fmt.Errorf("chtimes %s: %v", remotePath, err)
+		}
+	}
+	return nil
+}
+
+func downloadPath(sc *sftp.Client, remotePath, localPath string, preserve bool) error {
+	matches, err := sc.Glob(remotePath)
+	if err != nil {
+		return fmt.Errorf("glob %s: %v", remotePath, err)
+	}
+	if len(matches) == 0 {
+		matches = []string{remotePath}
+	}
+
+	for _, match := range matches {
+		info, err := sc.Stat(match)
+		if err != nil {
+			return fmt.Errorf("stat %s: %v", match, err)
+		}
+
+		dest := localPath
+		if len(matches) > 1 {
+			dest = filepath.Join(localPath, filepath.Base(match))
+		}
+
+		if info.IsDir() {
+			if err := downloadDir(sc, match, dest, preserve); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := downloadFile(sc, match, dest, info, preserve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadDir(sc *sftp.Client, remoteDir, localDir string, preserve bool) error {
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return fmt.Errorf("mkdir %s: %v", localDir, err)
+	}
+
+	entries, err := sc.ReadDir(remoteDir)
+	if err != nil {
+		return fmt.Errorf("read dir %s: %v", remoteDir, err)
+	}
+
+	for _, entry := range entries {
+		remoteChild := remoteDir + "/" + entry.Name()
+		localChild := filepath.Join(localDir, entry.Name())
+
+		if entry.IsDir() {
+			if err := downloadDir(sc, remoteChild, localChild, preserve); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := downloadFile(sc, remoteChild, localChild, entry, preserve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadFile(sc *sftp.Client, remotePath, localPath string, info os.FileInfo, preserve bool) error {
+	src, err := sc.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", remotePath, err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %v", localPath, err)
+	}
+	defer dst.Close()
+
+	if err := copyWithProgress(remotePath, dst, src, info.Size()); err != nil {
+		return fmt.Errorf("copy %s: %v", remotePath, err)
+	}
+
+	if preserve {
+		if err := os.Chmod(localPath, info.Mode()); err != nil {
+			return fmt.Errorf("chmod %s: %v", localPath, err)
+		}
+		mtime := info.ModTime()
+		if err := os.Chtimes(localPath, mtime, mtime); err != nil {
+			return fmt.Errorf("chtimes %s: %v", localPath, err)
+		}
+	}
+	return nil
+}
+
+// copyWithProgress는 src를 dst로 복사하면서, stderr가 터미널에 연결되어 있을 때만
+// label과 함께 진행률을 갱신한다.
+func copyWithProgress(label string, dst io.Writer, src io.Reader, total int64) error {
+	if !term.IsTerminal(int(os.Stderr.Fd())) || total <= 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	var written int64
+	buf := make([]byte, 32*1024)
+	lastPrint := time.Now()
+
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			written += int64(n)
+			if time.Since(lastPrint) > 100*time.Millisecond || written == total {
+				fmt.Fprintf(os.Stderr, "\r%s: %d%%", label, written*100/total)
+				lastPrint = time.Now()
+			}
+		}
+		if readErr == io.EOF {
+			fmt.Fprintln(os.Stderr)
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSplitHostPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		spec         string
+		wantHost     string
+		wantPath     string
+		wantIsRemote bool
+	}{
+		{name: "host and path", spec: "prod:/var/log/app.log", wantHost: "prod", wantPath: "/var/log/app.log", wantIsRemote: true},
+		{name: "host omitted, colon kept", spec: ":/var/log/app.log", wantHost: "", wantPath: "/var/log/app.log", wantIsRemote: true},
+		{name: "plain local path", spec: "/home/user/app.log", wantHost: "", wantPath: "/home/user/app.log", wantIsRemote: false},
+		{name: "relative path with colon in name is local", spec: "./a:b", wantHost: "", wantPath: "./a:b", wantIsRemote: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, path, isRemote := splitHostPath(tt.spec)
+			if host != tt.wantHost || path != tt.wantPath || isRemote != tt.wantIsRemote {
+				t.Errorf("splitHostPath(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.spec, host, path, isRemote, tt.wantHost, tt.wantPath, tt.wantIsRemote)
+			}
+		})
+	}
+}
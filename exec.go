@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// runCommand는 PTY 없이 client 위에서 command를 실행하고 세션의 종료 코드를 반환한다.
+// 원격 명령이 0이 아닌 상태로 끝난 경우에도 err는 nil이며, exitCode로만 구분한다.
+func runCommand(client *ssh.Client, command string, stdout, stderr io.Writer) (int, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return -1, fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Run(command); err != nil {
+		if exitErr, ok := err.(*ssh.ExitError); ok {
+			return exitErr.ExitStatus(), nil
+		}
+		return -1, err
+	}
+	return 0, nil
+}
+
+// execResult는 runExecFanout의 호스트별 실행 결과다.
+type execResult struct {
+	host     SSHHost
+	exitCode int
+	err      error
+}
+
+// runExecFanout은 hosts에 동시에 command를 실행한다. 동시성은 workers로 제한하고,
+// 각 줄 앞에 호스트 이름을 붙여 출력하며, 어느 한 호스트라도 실패하면 에러를 반환한다.
+func runExecFanout(hosts []SSHHost, allHosts []SSHHost, command string, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	useColor := term.IsTerminal(int(os.Stdout.Fd()))
+	var writeMu sync.Mutex // os.Stdout/os.Stderr에 호스트별 출력이 섞이지 않도록 직렬화
+
+	jobs := make(chan SSHHost)
+	results := make(chan execResult, len(hosts))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				results <- execOnHost(host, allHosts, command, &writeMu, useColor)
+			}
+		}()
+	}
+
+	go func() {
+		for _, h := range hosts {
+			jobs <- h
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	failed := false
+	for res := range results {
+		if res.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error: %v\n", res.host.Name, res.err)
+			failed = true
+		} else if res.exitCode != 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("one or more hosts failed")
+	}
+	return nil
+}
+
+func execOnHost(host SSHHost, allHosts []SSHHost, command string, writeMu *sync.Mutex, useColor bool) execResult {
+	client, err := openClient(host, allHosts)
+	if err != nil {
+		return execResult{host: host, exitCode: -1, err: err}
+	}
+	defer client.Close()
+
+	label := hostLabel(host.Name, useColor)
+	out := &prefixWriter{out: os.Stdout, prefix: label, mu: writeMu}
+	errOut := &prefixWriter{out: os.Stderr, prefix: label, mu: writeMu}
+
+	exitCode, err := runCommand(client, command, out, errOut)
+	out.Flush()
+	errOut.Flush()
+
+	return execResult{host: host, exitCode: exitCode, err: err}
+}
+
+// ansiColors는 호스트 이름에 순환 적용되는 색상 팔레트다.
+var ansiColors = []string{"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m"}
+
+// hostLabel은 각 출력 줄 앞에 붙는 "[host] " 접두사를 만든다. useColor가 true이면
+// 호스트 이름마다 안정적으로 고른 ANSI 색을 입힌다.
+func hostLabel(name string, useColor bool) string {
+	if !useColor {
+		return fmt.Sprintf("[%s] ", name)
+	}
+	sum := 0
+	for _, r := range name {
+		sum += int(r)
+	}
+	return fmt.Sprintf("%s[%s]\x1b[0m ", ansiColors[sum%len(ansiColors)], name)
+}
+
+// prefixWriter는 매 줄 앞에 prefix를 붙여 out에 쓴다. 여러 prefixWriter가 같은
+// out(os.Stdout 등)을 공유할 때는 mu로 줄 단위 출력을 직렬화해야 섞이지 않는다.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := w.buf[:idx]
+		w.buf = w.buf[idx+1:]
+		w.writeLine(line)
+	}
+	return len(p), nil
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s%s\n", w.prefix, line)
+}
+
+// Flush는 남아 있는 개행되지 않은 마지막 줄을 써낸다.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.writeLine(w.buf)
+	w.buf = nil
+}
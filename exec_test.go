@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHostLabel(t *testing.T) {
+	if got := hostLabel("web1", false); got != "[web1] " {
+		t.Errorf("hostLabel(no color) = %q, want %q", got, "[web1] ")
+	}
+
+	colored := hostLabel("web1", true)
+	if !strings.Contains(colored, "web1") {
+		t.Errorf("hostLabel(color) = %q, should contain host name", colored)
+	}
+	if !strings.HasPrefix(colored, "\x1b[") {
+		t.Errorf("hostLabel(color) = %q, should start with an ANSI escape", colored)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{out: &out, prefix: "[web1] ", mu: &mu}
+
+	if _, err := w.Write([]byte("line one\nline tw")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if _, err := w.Write([]byte("o\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	expected := "[web1] line one\n[web1] line two\n"
+	if out.String() != expected {
+		t.Errorf("prefixWriter output = %q, want %q", out.String(), expected)
+	}
+}
+
+func TestPrefixWriterFlush(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{out: &out, prefix: "[web1] ", mu: &mu}
+
+	w.Write([]byte("no trailing newline"))
+	w.Flush()
+
+	expected := "[web1] no trailing newline\n"
+	if out.String() != expected {
+		t.Errorf("prefixWriter output after Flush = %q, want %q", out.String(), expected)
+	}
+}
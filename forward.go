@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// forwardSpec는 ssh(1)의 -L/-R과 동일한 [bind_address:]port:host:hostport 스펙이다.
+type forwardSpec struct {
+	bindAddr string
+	bindPort string
+	destHost string
+	destPort string
+}
+
+func (f forwardSpec) bindAddress() string {
+	addr := f.bindAddr
+	if addr == "" {
+		addr = "localhost"
+	}
+	return net.JoinHostPort(addr, f.bindPort)
+}
+
+func (f forwardSpec) destAddress() string {
+	return net.JoinHostPort(f.destHost, f.destPort)
+}
+
+// parseForwardSpec는 "[bind_address:]port:host:hostport" 형식을 파싱한다.
+func parseForwardSpec(spec string) (forwardSpec, error) {
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 3:
+		return forwardSpec{bindPort: parts[0], destHost: parts[1], destPort: parts[2]}, nil
+	case 4:
+		return forwardSpec{bindAddr: parts[0], bindPort: parts[1], destHost: parts[2], destPort: parts[3]}, nil
+	default:
+		return forwardSpec{}, fmt.Errorf("invalid forward spec %q, want [bind_address:]port:host:hostport", spec)
+	}
+}
+
+// runForwarding은 host에 연결한 뒤 요청된 -L/-R/-D 포워딩을 모두 설정하고
+// Ctrl-C(SIGINT)가 올 때까지 블록한다. 인터랙티브 쉘과 달리 PTY는 필요하지 않으므로
+// openClient로 얻은 *ssh.Client를 바로 사용한다.
+func runForwarding(host SSHHost, allHosts []SSHHost, localSpecs, remoteSpecs []string, dynamicSpec string) error {
+	client, err := openClient(host, allHosts)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// 포워딩 모드에는 터미널 리사이즈가 없으므로 SIGWINCH가 기본 동작으로
+	// 프로세스를 종료시키지 않도록 무시한다.
+	signal.Ignore(syscall.SIGWINCH)
+
+	var wg sync.WaitGroup
+	var listeners []net.Listener
+
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+
+	for _, raw := range localSpecs {
+		spec, err := parseForwardSpec(raw)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		ln, err := net.Listen("tcp", spec.bindAddress())
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("local forward %s: %v", raw, err)
+		}
+		listeners = append(listeners, ln)
+		fmt.Fprintf(os.Stderr, "Local forward:  %s -> %s\n", spec.bindAddress(), spec.destAddress())
+		wg.Add(1)
+		go func(spec forwardSpec, ln net.Listener) {
+			defer wg.Done()
+			serveLocalForward(client, ln, spec)
+		}(spec, ln)
+	}
+
+	for _, raw := range remoteSpecs {
+		spec, err := parseForwardSpec(raw)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		ln, err := client.Listen("tcp", spec.bindAddress())
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("remote forward %s: %v", raw, err)
+		}
+		listeners = append(listeners, ln)
+		fmt.Fprintf(os.Stderr, "Remote forward: %s -> %s\n", spec.bindAddress(), spec.destAddress())
+		wg.Add(1)
+		go func(spec forwardSpec, ln net.Listener) {
+			defer wg.Done()
+			serveRemoteForward(client, ln, spec)
+		}(spec, ln)
+	}
+
+	if dynamicSpec != "" {
+		bindAddr := dynamicSpec
+		if !strings.Contains(bindAddr, ":") {
+			bindAddr = "localhost:" + bindAddr
+		}
+		ln, err := net.Listen("tcp", bindAddr)
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("dynamic forward %s: %v", dynamicSpec, err)
+		}
+		listeners = append(listeners, ln)
+		fmt.Fprintf(os.Stderr, "Dynamic forward (SOCKS5): listening on %s\n", bindAddr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveSOCKS5(client, ln)
+		}()
+	}
+
+	if len(listeners) == 0 {
+		return fmt.Errorf("no forwards requested")
+	}
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt)
+	<-sigint
+	fmt.Fprintln(os.Stderr, "\nClosing forwards...")
+	closeAll()
+	wg.Wait()
+	return nil
+}
+
+func serveLocalForward(client *ssh.Client, ln net.Listener, spec forwardSpec) {
+	for {
+		local, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer local.Close()
+			remote, err := client.Dial("tcp", spec.destAddress())
+			if err != nil {
+				debugLog("local forward: dial %s failed: %v", spec.destAddress(), err)
+				return
+			}
+			defer remote.Close()
+			pipe(local, remote)
+		}()
+	}
+}
+
+func serveRemoteForward(client *ssh.Client, ln net.Listener, spec forwardSpec) {
+	for {
+		remote, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer remote.Close()
+			local, err := net.Dial("tcp", spec.destAddress())
+			if err != nil {
+				debugLog("remote forward: dial %s failed: %v", spec.destAddress(), err)
+				return
+			}
+			defer local.Close()
+			pipe(remote, local)
+		}()
+	}
+}
+
+// pipe는 두 연결 사이를 양방향으로 복사하고, 어느 한쪽이든 닫히면 반환한다.
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
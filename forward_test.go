@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestParseForwardSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected forwardSpec
+		wantErr  bool
+	}{
+		{
+			name:     "port:host:hostport",
+			spec:     "8080:db.internal:5432",
+			expected: forwardSpec{bindPort: "8080", destHost: "db.internal", destPort: "5432"},
+		},
+		{
+			name:     "bind_address:port:host:hostport",
+			spec:     "127.0.0.1:8080:db.internal:5432",
+			expected: forwardSpec{bindAddr: "127.0.0.1", bindPort: "8080", destHost: "db.internal", destPort: "5432"},
+		},
+		{
+			name:    "invalid spec",
+			spec:    "8080",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseForwardSpec(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseForwardSpec(%q) expected error, got nil", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseForwardSpec(%q) error = %v", tt.spec, err)
+			}
+			if got != tt.expected {
+				t.Errorf("parseForwardSpec(%q) = %+v, want %+v", tt.spec, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestForwardSpecAddresses(t *testing.T) {
+	spec := forwardSpec{bindPort: "8080", destHost: "db.internal", destPort: "5432"}
+	if got := spec.bindAddress(); got != "localhost:8080" {
+		t.Errorf("bindAddress() = %q, want %q (default to localhost)", got, "localhost:8080")
+	}
+	if got := spec.destAddress(); got != "db.internal:5432" {
+		t.Errorf("destAddress() = %q, want %q", got, "db.internal:5432")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// StrictHostKeyChecking 모드. 처음 보는 호스트에 대해 yes는 연결을 거부하고,
+// no는 검증 없이 허용하되 known_hosts에 기록하지 않는다. accept-new(기본값)와
+// 그 밖의 값(예: ask)은 ssh_config(5)의 accept-new와 달리 자동으로 추가하지 않고,
+// TOFU 프롬프트에서 수락한 경우에만 known_hosts에 추가한다.
+const (
+	strictHostKeyCheckingYes       = "yes"
+	strictHostKeyCheckingNo        = "no"
+	strictHostKeyCheckingAcceptNew = "accept-new"
+)
+
+func defaultKnownHostsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// hostKeyCallback은 host.UserKnownHostsFile(없으면 ~/.ssh/known_hosts)을 기반으로
+// ssh.HostKeyCallback을 구성한다. 처음 보는 호스트는 StrictHostKeyChecking과
+// MC_STRICT_HOST_KEY 환경변수에 따라 TOFU(Trust On First Use) 방식으로 처리하고,
+// 이미 알고 있는 호스트의 키가 바뀐 경우에는 항상 연결을 거부한다.
+func hostKeyCallback(host SSHHost) (ssh.HostKeyCallback, error) {
+	knownHostsPath := host.UserKnownHostsFile
+	if knownHostsPath == "" {
+		knownHostsPath = defaultKnownHostsPath()
+	}
+	if knownHostsPath == "" {
+		return nil, fmt.Errorf("cannot determine known_hosts path")
+	}
+
+	if _, err := os.Stat(knownHostsPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", filepath.Dir(knownHostsPath), err)
+		}
+		f, err := os.OpenFile(knownHostsPath, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", knownHostsPath, err)
+		}
+		f.Close()
+	}
+
+	db, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %v", err)
+	}
+
+	strict := strictHostKeyMode(host)
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := db(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) > 0 {
+			// known_hosts에 기록된 키와 다름 - man-in-the-middle 가능성, 무조건 거부
+			return fmt.Errorf("REMOTE HOST IDENTIFICATION HAS CHANGED for %s! "+
+				"Someone could be eavesdropping on you right now (man-in-the-middle attack). "+
+				"Expected fingerprint %s but got %s",
+				hostname, ssh.FingerprintSHA256(keyErr.Want[0].Key), ssh.FingerprintSHA256(key))
+		}
+
+		// 처음 보는 호스트
+		switch strict {
+		case strictHostKeyCheckingNo:
+			debugLog("StrictHostKeyChecking=no, skipping verification for %s", hostname)
+			return nil
+		case strictHostKeyCheckingYes:
+			return fmt.Errorf("host key verification failed: %s is not in %s (StrictHostKeyChecking=yes)", hostname, knownHostsPath)
+		default: // accept-new
+			if !promptTOFU(hostname, key) {
+				return fmt.Errorf("host key verification failed: user rejected %s", hostname)
+			}
+		}
+
+		if err := appendKnownHost(knownHostsPath, hostname, key); err != nil {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// strictHostKeyMode는 MC_STRICT_HOST_KEY 환경변수를 host.StrictHostKeyChecking보다
+// 우선시하여 accept-new/yes/no 모드를 결정한다.
+func strictHostKeyMode(host SSHHost) string {
+	if override := os.Getenv("MC_STRICT_HOST_KEY"); override != "" {
+		return strings.ToLower(override)
+	}
+	if host.StrictHostKeyChecking != "" {
+		return host.StrictHostKeyChecking
+	}
+	return strictHostKeyCheckingAcceptNew
+}
+
+// promptTOFU는 처음 접속하는 호스트의 키 지문을 보여주고 yes/no/fingerprint를 묻는다.
+// stdinPromptMu로 직렬화되므로, "mc -e <command> -j N"처럼 여러 호스트에 동시 접속할 때도
+// 한 번에 하나의 호스트만 프롬프트를 띄운다.
+func promptTOFU(hostname string, key ssh.PublicKey) bool {
+	stdinPromptMu.Lock()
+	defer stdinPromptMu.Unlock()
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", hostname)
+		fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), fingerprint)
+		fmt.Fprint(os.Stderr, "Are you sure you want to continue connecting (yes/no/[fingerprint])? ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+
+		answer := strings.TrimSpace(line)
+		switch strings.ToLower(answer) {
+		case "yes":
+			return true
+		case "no", "":
+			return false
+		}
+
+		// 사용자가 직접 지문을 타이핑해서 확인한 경우도 "yes"와 동일하게
+		// 받아들인다(ssh(1)도 [fingerprint]를 그대로 답하면 수락으로 처리한다).
+		// 그 외 알 수 없는 입력은 지문을 다시 보여주고 재질문한다.
+		if strings.EqualFold(answer, fingerprint) {
+			return true
+		}
+	}
+}
+
+// appendKnownHost는 known_hosts 파일에 새 항목을 덧붙인다. 같은 파일에 여러
+// goroutine이 동시에 쓸 수 있으므로(예: -e ... -j N으로 여러 호스트를 처음 접속),
+// stdinPromptMu로 같이 직렬화해 append가 서로 깨뜨리지 않게 한다.
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	stdinPromptMu.Lock()
+	defer stdinPromptMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{hostname}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("failed to append known_hosts entry: %v", err)
+	}
+	debugLog("Added new host key for %s to %s", hostname, path)
+	return nil
+}
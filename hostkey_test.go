@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStrictHostKeyMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     SSHHost
+		env      string
+		expected string
+	}{
+		{
+			name:     "default is accept-new",
+			host:     SSHHost{},
+			expected: strictHostKeyCheckingAcceptNew,
+		},
+		{
+			name:     "host config wins over default",
+			host:     SSHHost{StrictHostKeyChecking: "yes"},
+			expected: "yes",
+		},
+		{
+			name:     "env var overrides host config",
+			host:     SSHHost{StrictHostKeyChecking: "yes"},
+			env:      "no",
+			expected: "no",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				os.Setenv("MC_STRICT_HOST_KEY", tt.env)
+				defer os.Unsetenv("MC_STRICT_HOST_KEY")
+			} else {
+				os.Unsetenv("MC_STRICT_HOST_KEY")
+			}
+
+			if got := strictHostKeyMode(tt.host); got != tt.expected {
+				t.Errorf("strictHostKeyMode() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultKnownHostsPath(t *testing.T) {
+	path := defaultKnownHostsPath()
+	if path == "" {
+		t.Skip("cannot get home directory")
+	}
+
+	if got := path[len(path)-len("known_hosts"):]; got != "known_hosts" {
+		t.Errorf("defaultKnownHostsPath() = %q, should end with known_hosts", path)
+	}
+}
@@ -2,28 +2,40 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/ktr0731/go-fuzzyfinder"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/term"
 )
 
 type SSHHost struct {
-	Name         string
-	HostName     string
-	Port         string
-	User         string
-	Comment      string
-	IdentityFile string
+	Name                     string
+	HostName                 string
+	Port                     string
+	User                     string
+	Comment                  string
+	IdentityFile             string
+	StrictHostKeyChecking    string
+	UserKnownHostsFile       string
+	ProxyJump                string
+	ProxyCommand             string
+	ConnectTimeout           string
+	ServerAliveInterval      string
+	IdentitiesOnly           bool
+	PreferredAuthentications string
+	AuthenticationMethods    string
+	PKCS11Provider           string
 }
 
 func (h SSHHost) Display() string {
@@ -43,94 +55,6 @@ func (h SSHHost) Display() string {
 	return info
 }
 
-func parseSSHConfig(path string) ([]SSHHost, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var hosts []SSHHost
-	var current *SSHHost
-	var lastComment string
-
-	hostRe := regexp.MustCompile(`(?i)^Host\s+(.+)$`)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// 주석 저장
-		if strings.HasPrefix(line, "#") {
-			lastComment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
-			continue
-		}
-
-		if line == "" {
-			continue
-		}
-
-		// Host 라인 파싱
-		if matches := hostRe.FindStringSubmatch(line); matches != nil {
-			hostName := matches[1]
-			// 이전 호스트 저장
-			if current != nil {
-				hosts = append(hosts, *current)
-			}
-			// 와일드카드 호스트 제외
-			if strings.Contains(hostName, "*") {
-				current = nil
-				continue
-			}
-			current = &SSHHost{
-				Name:    hostName,
-				Port:    "22",
-				Comment: lastComment,
-			}
-			lastComment = ""
-			continue
-		}
-
-		if current == nil {
-			continue
-		}
-
-		// 속성 파싱 (공백 또는 = 구분자 지원)
-		var key, value string
-		if idx := strings.Index(line, "="); idx != -1 {
-			key = strings.ToLower(strings.TrimSpace(line[:idx]))
-			value = strings.TrimSpace(line[idx+1:])
-		} else {
-			parts := strings.SplitN(line, " ", 2)
-			if len(parts) < 2 {
-				parts = strings.SplitN(line, "\t", 2)
-				if len(parts) < 2 {
-					continue
-				}
-			}
-			key = strings.ToLower(strings.TrimSpace(parts[0]))
-			value = strings.TrimSpace(parts[1])
-		}
-
-		switch key {
-		case "hostname":
-			current.HostName = value
-		case "port":
-			current.Port = value
-		case "user":
-			current.User = value
-		case "identityfile":
-			current.IdentityFile = expandPath(value)
-		}
-	}
-
-	if current != nil {
-		hosts = append(hosts, *current)
-	}
-
-	return hosts, scanner.Err()
-}
-
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, err := os.UserHomeDir()
@@ -164,51 +88,11 @@ func getDefaultKeyPaths() []string {
 	}
 }
 
-func getSSHAgentAuth() ssh.AuthMethod {
-	socket := os.Getenv("SSH_AUTH_SOCK")
-	if socket == "" {
-		return nil
-	}
-
-	conn, err := net.Dial("unix", socket)
-	if err != nil {
-		return nil
-	}
-
-	agentClient := agent.NewClient(conn)
-	return ssh.PublicKeysCallback(agentClient.Signers)
-}
-
-func getKeyAuth(keyPath string) ssh.AuthMethod {
-	key, err := os.ReadFile(keyPath)
-	if err != nil {
-		return nil
-	}
-
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		// 암호화된 키인 경우 패스워드 요청
-		if strings.Contains(err.Error(), "passphrase") {
-			fmt.Printf("Enter passphrase for key '%s': ", keyPath)
-			passphrase, err := term.ReadPassword(int(syscall.Stdin))
-			fmt.Println()
-			if err != nil {
-				return nil
-			}
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
-			if err != nil {
-				return nil
-			}
-		} else {
-			return nil
-		}
-	}
-
-	return ssh.PublicKeys(signer)
-}
-
 func getPasswordAuth() ssh.AuthMethod {
 	return ssh.PasswordCallback(func() (string, error) {
+		stdinPromptMu.Lock()
+		defer stdinPromptMu.Unlock()
+
 		fmt.Print("Password: ")
 		password, err := term.ReadPassword(int(syscall.Stdin))
 		fmt.Println()
@@ -221,6 +105,9 @@ func getPasswordAuth() ssh.AuthMethod {
 
 func getKeyboardInteractiveAuth() ssh.AuthMethod {
 	return ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		stdinPromptMu.Lock()
+		defer stdinPromptMu.Unlock()
+
 		if instruction != "" {
 			fmt.Println(instruction)
 		}
@@ -241,6 +128,14 @@ func getKeyboardInteractiveAuth() ssh.AuthMethod {
 	})
 }
 
+// stdinPromptMu는 os.Stdin/콘솔에서 직접 읽고 쓰는 모든 인터랙티브 프롬프트
+// (TOFU 확인, 패스프레이즈/패스워드, PKCS#11 PIN, keyboard-interactive 질문)를
+// 직렬화한다. "mc -e <command> -j N"처럼 여러 호스트를 동시에 처리할 때 각 워커
+// goroutine의 openClient가 동시에 이런 프롬프트를 띄우면 터미널 입출력이
+// 뒤섞여 어느 쪽 답도 제대로 전달되지 않으므로, 한 번에 하나의 프롬프트만
+// 진행되도록 막는다.
+var stdinPromptMu sync.Mutex
+
 var verbose = os.Getenv("MC_DEBUG") != ""
 
 func debugLog(format string, args ...interface{}) {
@@ -249,7 +144,16 @@ func debugLog(format string, args ...interface{}) {
 	}
 }
 
-func connectSSH(host SSHHost) error {
+// buildAuthMethods는 host 설정(PreferredAuthentications, AuthenticationMethods,
+// IdentitiesOnly)을 AuthChain에 맡겨 인증 방법 목록을 만든다.
+func buildAuthMethods(host SSHHost) []ssh.AuthMethod {
+	return NewAuthChain(host).Methods()
+}
+
+// openClient는 호스트 설정(인증, known_hosts 검증, ProxyJump)을 모두 반영해
+// *ssh.Client를 만든다. PTY/쉘 설정은 여기서 다루지 않으므로 인터랙티브 접속,
+// 포트 포워딩, 원격 명령 실행 등 연결을 재사용하는 모든 모드가 이 함수를 공유한다.
+func openClient(host SSHHost, allHosts []SSHHost) (*ssh.Client, error) {
 	// 호스트 주소 결정
 	hostname := host.HostName
 	if hostname == "" {
@@ -266,61 +170,80 @@ func connectSSH(host SSHHost) error {
 	debugLog("Connecting to %s@%s", user, addr)
 	debugLog("IdentityFile from config: %s", host.IdentityFile)
 
-	// 인증 방법 수집
-	var authMethods []ssh.AuthMethod
-
-	// 1. 지정된 IdentityFile (최우선)
-	if host.IdentityFile != "" {
-		debugLog("Trying IdentityFile: %s", host.IdentityFile)
-		if _, err := os.Stat(host.IdentityFile); err != nil {
-			debugLog("IdentityFile not found: %v", err)
-		} else if keyAuth := getKeyAuth(host.IdentityFile); keyAuth != nil {
-			debugLog("Added key auth from IdentityFile: %s", host.IdentityFile)
-			authMethods = append(authMethods, keyAuth)
-		} else {
-			debugLog("Failed to load key from IdentityFile: %s", host.IdentityFile)
-		}
+	hostKeyCB, err := hostKeyCallback(host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up host key verification: %v", err)
 	}
 
-	// 2. SSH Agent (IdentityFile이 없는 경우에만)
-	if host.IdentityFile == "" {
-		if agentAuth := getSSHAgentAuth(); agentAuth != nil {
-			debugLog("Added SSH Agent auth")
-			authMethods = append(authMethods, agentAuth)
-		}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            buildAuthMethods(host),
+		HostKeyCallback: hostKeyCB,
+		Timeout:         connectTimeout(host),
 	}
 
-	// 3. 기본 키 파일들 (IdentityFile이 없는 경우에만)
-	if host.IdentityFile == "" {
-		for _, keyPath := range getDefaultKeyPaths() {
-			if _, err := os.Stat(keyPath); err == nil {
-				if keyAuth := getKeyAuth(keyPath); keyAuth != nil {
-					debugLog("Added key auth from default key: %s", keyPath)
-					authMethods = append(authMethods, keyAuth)
-				}
-			}
-		}
+	// SSH 연결 (ProxyJump이 설정된 경우 중간 호스트를 거쳐서 연결)
+	client, err := dialClient(host, addr, config, allHosts)
+	if err != nil {
+		return nil, err
 	}
+	startKeepalive(client, host)
+	return client, nil
+}
 
-	// 4. 패스워드 인증
-	authMethods = append(authMethods, getPasswordAuth())
-
-	// 5. Keyboard Interactive
-	authMethods = append(authMethods, getKeyboardInteractiveAuth())
+// connectTimeout은 host.ConnectTimeout(초 단위 문자열)을 ssh.ClientConfig.Timeout으로
+// 쓸 수 있는 time.Duration으로 바꾼다. 설정되지 않았거나 잘못된 값이면 0(무제한)을
+// 돌려줘 기존 동작을 그대로 유지한다.
+func connectTimeout(host SSHHost) time.Duration {
+	if host.ConnectTimeout == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(host.ConnectTimeout)
+	if err != nil || seconds <= 0 {
+		debugLog("ConnectTimeout: ignoring invalid value %q", host.ConnectTimeout)
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
 
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 실제 환경에서는 known_hosts 검증 필요
+// startKeepalive는 host.ServerAliveInterval이 설정된 경우 그 주기로
+// keepalive@openssh.com 전역 요청을 보내 죽은 연결을 빨리 감지한다(ssh(1)의
+// ServerAliveInterval과 동일). 연결이 끊기면 요청이 실패하므로 고루틴은 조용히 끝난다.
+func startKeepalive(client *ssh.Client, host SSHHost) {
+	if host.ServerAliveInterval == "" {
+		return
+	}
+	seconds, err := strconv.Atoi(host.ServerAliveInterval)
+	if err != nil || seconds <= 0 {
+		debugLog("ServerAliveInterval: ignoring invalid value %q", host.ServerAliveInterval)
+		return
 	}
 
-	// SSH 연결
-	client, err := ssh.Dial("tcp", addr, config)
+	interval := time.Duration(seconds) * time.Second
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				debugLog("ServerAliveInterval: keepalive failed, connection likely closed: %v", err)
+				return
+			}
+		}
+	}()
+}
+
+func connectSSH(host SSHHost, allHosts []SSHHost) error {
+	client, err := openClient(host, allHosts)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %v", err)
 	}
 	defer client.Close()
 
+	return runInteractive(client)
+}
+
+// runInteractive는 이미 연결된 client 위에 PTY를 요청하고 인터랙티브 쉘을 시작한다.
+func runInteractive(client *ssh.Client) error {
 	// 세션 생성
 	session, err := client.NewSession()
 	if err != nil {
@@ -384,7 +307,94 @@ func connectSSH(host SSHHost) error {
 	return session.Wait()
 }
 
+// errSelectionCancelled는 fuzzyfinder에서 ESC/Ctrl+C로 선택을 취소했을 때 반환된다.
+var errSelectionCancelled = errors.New("selection cancelled")
+
+// pickHost는 go-fuzzyfinder로 hosts 중 하나를 선택하게 한다. initialQuery는
+// 커맨드 라인에 남은 인자를 검색어로 미리 채우는 데 쓰인다.
+func pickHost(hosts []SSHHost, initialQuery string) (SSHHost, error) {
+	idx, err := fuzzyfinder.Find(
+		hosts,
+		func(i int) string {
+			return hosts[i].Display()
+		},
+		fuzzyfinder.WithPromptString("SSH > "),
+		fuzzyfinder.WithHeader("Select a host to connect"),
+		fuzzyfinder.WithCursorPosition(fuzzyfinder.CursorPositionTop),
+		fuzzyfinder.WithQuery(initialQuery),
+		fuzzyfinder.WithPreviewWindow(func(i, w, h int) string {
+			if i == -1 {
+				return ""
+			}
+			host := hosts[i]
+			return fmt.Sprintf("Name:     %s\nHost:     %s\nUser:     %s\nPort:     %s\nKey:      %s\nComment:  %s",
+				host.Name, host.HostName, host.User, host.Port, host.IdentityFile, host.Comment)
+		}),
+	)
+	if err != nil {
+		return SSHHost{}, errSelectionCancelled
+	}
+	return hosts[idx], nil
+}
+
+// pickHosts는 fuzzyfinder.FindMulti로 여러 호스트를 선택하게 한다 (Tab으로 표시,
+// Enter로 확정 - fzf --multi와 동일한 방식). -e 팬아웃 실행에서 사용한다.
+func pickHosts(hosts []SSHHost, initialQuery string) ([]SSHHost, error) {
+	idxs, err := fuzzyfinder.FindMulti(
+		hosts,
+		func(i int) string {
+			return hosts[i].Display()
+		},
+		fuzzyfinder.WithPromptString("SSH > "),
+		fuzzyfinder.WithHeader("Tab to mark hosts, Enter to run the command on all marked hosts"),
+		fuzzyfinder.WithCursorPosition(fuzzyfinder.CursorPositionTop),
+		fuzzyfinder.WithQuery(initialQuery),
+	)
+	if err != nil {
+		return nil, errSelectionCancelled
+	}
+
+	selected := make([]SSHHost, len(idxs))
+	for i, idx := range idxs {
+		selected[i] = hosts[idx]
+	}
+	return selected, nil
+}
+
+// forwardFlags는 반복 가능한 -L/-R 플래그 값을 모은다 (flag.Value 구현).
+type forwardFlags []string
+
+func (f *forwardFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *forwardFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func main() {
+	defer ClosePKCS11Sessions()
+	defer CleanupSSHConfigTemp()
+
+	if len(os.Args) > 1 && os.Args[1] == "cp" {
+		if err := runCopyCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "mc cp: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var localForwards, remoteForwards forwardFlags
+	var dynamicForward, execCommand string
+	var jobs int
+	flag.Var(&localForwards, "L", "local port forward: [bind_address:]port:host:hostport")
+	flag.Var(&remoteForwards, "R", "remote port forward: [bind_address:]port:host:hostport")
+	flag.StringVar(&dynamicForward, "D", "", "dynamic SOCKS5 proxy: [bind_address:]port")
+	flag.StringVar(&execCommand, "e", "", "run command on the selected host(s) instead of opening a shell")
+	flag.IntVar(&jobs, "j", 4, "number of hosts to run -e against concurrently")
+	flag.Parse()
+
 	configPath := getSSHConfigPath()
 	if configPath == "" {
 		fmt.Fprintln(os.Stderr, "Error: cannot find home directory")
@@ -402,42 +412,39 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 초기 검색어 설정 (커맨드 라인 인자)
-	initialQuery := ""
-	if len(os.Args) > 1 {
-		initialQuery = strings.Join(os.Args[1:], " ")
+	// 초기 검색어 설정 (커맨드 라인에 남은 인자)
+	initialQuery := strings.Join(flag.Args(), " ")
+
+	if execCommand != "" {
+		selected, err := pickHosts(hosts, initialQuery)
+		if err != nil {
+			// 취소됨 (ESC 또는 Ctrl+C)
+			os.Exit(0)
+		}
+		if err := runExecFanout(selected, hosts, execCommand, jobs); err != nil {
+			os.Exit(1)
+		}
+		return
 	}
 
-	// go-fuzzyfinder로 호스트 선택
-	idx, err := fuzzyfinder.Find(
-		hosts,
-		func(i int) string {
-			return hosts[i].Display()
-		},
-		fuzzyfinder.WithPromptString("SSH > "),
-		fuzzyfinder.WithHeader("Select a host to connect"),
-		fuzzyfinder.WithCursorPosition(fuzzyfinder.CursorPositionTop),
-		fuzzyfinder.WithQuery(initialQuery),
-		fuzzyfinder.WithPreviewWindow(func(i, w, h int) string {
-			if i == -1 {
-				return ""
-			}
-			host := hosts[i]
-			return fmt.Sprintf("Name:     %s\nHost:     %s\nUser:     %s\nPort:     %s\nKey:      %s\nComment:  %s",
-				host.Name, host.HostName, host.User, host.Port, host.IdentityFile, host.Comment)
-		}),
-	)
+	host, err := pickHost(hosts, initialQuery)
 	if err != nil {
 		// 취소됨 (ESC 또는 Ctrl+C)
 		os.Exit(0)
 	}
 
-	host := hosts[idx]
+	if len(localForwards) > 0 || len(remoteForwards) > 0 || dynamicForward != "" {
+		if err := runForwarding(host, hosts, localForwards, remoteForwards, dynamicForward); err != nil {
+			fmt.Fprintf(os.Stderr, "mc: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// SSH 접속
 	fmt.Printf("Connecting to %s...\n", host.Name)
-	if err := connectSSH(host); err != nil {
+	if err := connectSSH(host, hosts); err != nil {
 		fmt.Fprintf(os.Stderr, "SSH error: %v\n", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
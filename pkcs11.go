@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"syscall"
+
+	"github.com/miekg/pkcs11"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+var (
+	pkcs11CleanupMu sync.Mutex
+	pkcs11Cleanup   []func()
+)
+
+// ClosePKCS11Sessions은 loadPKCS11Signers가 열어 둔 PKCS#11 세션을 모두
+// 로그아웃/종료하고 모듈을 언로드한다. 서명은 연결이 완전히 끝날 때까지
+// (핸드셰이크 중 AuthChain이 반환한 ssh.Signer를 실제로 쓸 때까지) 계속
+// 토큰에 접근해야 하므로, 키를 모은 직후가 아니라 프로그램이 더 이상 그
+// signer를 쓰지 않게 된 시점(main의 defer)에 호출해야 한다.
+func ClosePKCS11Sessions() {
+	pkcs11CleanupMu.Lock()
+	cleanups := pkcs11Cleanup
+	pkcs11Cleanup = nil
+	pkcs11CleanupMu.Unlock()
+
+	for _, cleanup := range cleanups {
+		cleanup()
+	}
+}
+
+// loadPKCS11Signers는 providerPath가 가리키는 PKCS#11 모듈(.so)을 열어 토큰에
+// 꽂혀 있는 키 쌍들을 ssh.Signer로 감싼다. ssh-agent의 "ssh-add -s <module>"이
+// 하는 일을 에이전트 없이 직접 수행하는 셈이다. 개인 키 자체는 메모리로 가져오지
+// 않고, 서명은 항상 토큰 안(C_Sign)에서 이뤄진다.
+func loadPKCS11Signers(providerPath string) ([]ssh.Signer, error) {
+	p := pkcs11.New(providerPath)
+	if p == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", providerPath)
+	}
+	if err := p.Initialize(); err != nil {
+		return nil, fmt.Errorf("initialize %s: %v", providerPath, err)
+	}
+
+	slots, err := p.GetSlotList(true)
+	if err != nil {
+		p.Destroy()
+		return nil, fmt.Errorf("list slots: %v", err)
+	}
+
+	// PIN은 모듈(providerPath)당 한 번만 묻고, 모든 슬롯의 로그인에 같은 PIN을
+	// 쓴다 - 슬롯마다 새로 물어보면 여러 토큰/슬롯이 꽂혀 있을 때 똑같은 PIN을
+	// 반복 입력해야 했다. 토큰마다 PIN이 다르면 나머지 슬롯의 로그인은 실패한다.
+	pin := promptPKCS11PIN(providerPath)
+
+	var signers []ssh.Signer
+	var openSessions []pkcs11.SessionHandle
+	for _, slot := range slots {
+		session, err := p.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+		if err != nil {
+			debugLog("PKCS#11: failed to open session on slot %d: %v", slot, err)
+			continue
+		}
+
+		if err := p.Login(session, pkcs11.CKU_USER, pin); err != nil {
+			debugLog("PKCS#11: login failed on slot %d: %v", slot, err)
+			p.CloseSession(session)
+			continue
+		}
+		openSessions = append(openSessions, session)
+
+		found, err := findPKCS11Signers(p, session)
+		if err != nil {
+			debugLog("PKCS#11: failed to enumerate keys on slot %d: %v", slot, err)
+		}
+		signers = append(signers, found...)
+	}
+
+	pkcs11CleanupMu.Lock()
+	pkcs11Cleanup = append(pkcs11Cleanup, func() {
+		for _, session := range openSessions {
+			p.Logout(session)
+			p.CloseSession(session)
+		}
+		p.Destroy()
+	})
+	pkcs11CleanupMu.Unlock()
+
+	return signers, nil
+}
+
+// promptPKCS11PIN은 stdinPromptMu로 직렬화된다 - -e ... -j N으로 여러 호스트가
+// 동시에 PKCS#11 토큰을 쓰면, 직렬화하지 않을 경우 PIN 프롬프트가 서로 겹쳐
+// 어느 goroutine도 제대로 된 입력을 받지 못한다.
+func promptPKCS11PIN(providerPath string) string {
+	stdinPromptMu.Lock()
+	defer stdinPromptMu.Unlock()
+
+	fmt.Printf("Enter PIN for PKCS#11 token (%s): ", providerPath)
+	pin, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return ""
+	}
+	return string(pin)
+}
+
+// findPKCS11Signers는 세션에서 인증서 객체를 찾아 공개키를 얻고, 같은 CKA_ID를
+// 가진 개인 키 핸들과 짝지어 crypto.Signer로 감싼다.
+func findPKCS11Signers(p *pkcs11.Ctx, session pkcs11.SessionHandle) ([]ssh.Signer, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+	}
+	if err := p.FindObjectsInit(session, template); err != nil {
+		return nil, err
+	}
+	defer p.FindObjectsFinal(session)
+
+	objs, _, err := p.FindObjects(session, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []ssh.Signer
+	for _, obj := range objs {
+		attrs, err := p.GetAttributeValue(session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+		})
+		if err != nil {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(attrs[0].Value)
+		if err != nil {
+			continue
+		}
+
+		switch cert.PublicKey.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			// 지원하는 키 타입 - 계속 진행한다.
+		default:
+			// 예: Ed25519. pkcs11PrivateKey.Sign은 RSA/ECDSA 메커니즘만 알고
+			// 그 외 타입에는 에러를 돌려주므로, 쓸 수 없는 서명자를 여기서
+			// 미리 거른다.
+			debugLog("PKCS#11: skipping key with unsupported type %T (only RSA and ECDSA are supported)", cert.PublicKey)
+			continue
+		}
+
+		priv := &pkcs11PrivateKey{ctx: p, session: session, keyID: attrs[1].Value, pub: cert.PublicKey}
+		signer, err := ssh.NewSignerFromSigner(priv)
+		if err != nil {
+			debugLog("PKCS#11: cannot wrap key as ssh.Signer: %v", err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// pkcs11PrivateKey는 crypto.Signer를 구현해 ssh.NewSignerFromSigner로 감쌀 수
+// 있게 한다. Sign은 매번 토큰에서 해당 CKA_ID의 개인 키 핸들을 찾아 C_Sign을
+// 호출하므로, 개인 키 바이트 자체는 프로세스 메모리에 들어오지 않는다.
+type pkcs11PrivateKey struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	keyID   []byte
+	pub     crypto.PublicKey
+}
+
+func (k *pkcs11PrivateKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign은 crypto.Signer 계약(이미 해시된 digest를 받아 서명을 반환)을 PKCS#11의
+// C_Sign과 맞춘다. RSA는 CKM_RSA_PKCS가 DigestInfo(해시 OID + digest) 앞에
+// PKCS#1 v1.5 패딩만 덧붙이므로 DigestInfo를 직접 앞에 붙여줘야 하고, ECDSA는
+// CKM_ECDSA가 raw r||s를 돌려주므로 ssh 패키지가 기대하는 ASN.1 DER로 바꿔야
+// 한다. 둘 다 생략하면 서버가 서명을 거부한다.
+func (k *pkcs11PrivateKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, k.keyID),
+	}
+	if err := k.ctx.FindObjectsInit(k.session, template); err != nil {
+		return nil, err
+	}
+	defer k.ctx.FindObjectsFinal(k.session)
+
+	objs, _, err := k.ctx.FindObjects(k.session, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("PKCS#11: private key not found for id %x", k.keyID)
+	}
+
+	input := digest
+	var mechanism *pkcs11.Mechanism
+	switch pub := k.pub.(type) {
+	case *rsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		prefix, ok := rsaDigestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("PKCS#11: unsupported hash %v for RSA signing", opts.HashFunc())
+		}
+		input = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+	default:
+		// findPKCS11Signers는 이미 RSA/ECDSA가 아닌 키를 걸러내지만, Sign은
+		// CKM_ECDSA로 조용히 잘못 서명하는 대신 여기서도 한 번 더 막아
+		// "성공한 것처럼 보이는 깨진 서명"이 나갈 길을 없앤다.
+		return nil, fmt.Errorf("PKCS#11: unsupported public key type %T (only RSA and ECDSA are supported)", pub)
+	}
+
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{mechanism}, objs[0]); err != nil {
+		return nil, err
+	}
+	sig, err := k.ctx.Sign(k.session, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := k.pub.(*ecdsa.PublicKey); ok {
+		return encodeECDSASignature(sig)
+	}
+	return sig, nil
+}
+
+// rsaDigestInfoPrefixes는 RFC 3447의 DigestInfo DER 접두사(해시 OID +
+// 길이 필드)로, CKM_RSA_PKCS에 넘길 입력을 digest 앞에 이어붙이는 데 쓴다.
+var rsaDigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// encodeECDSASignature는 CKM_ECDSA가 돌려주는 raw r||s(각각 커브 차수 바이트
+// 길이로 고정폭)를 golang.org/x/crypto/ssh가 기대하는 ASN.1 DER
+// SEQUENCE{r, s}로 바꾼다.
+func encodeECDSASignature(sig []byte) ([]byte, error) {
+	if len(sig)%2 != 0 {
+		return nil, fmt.Errorf("PKCS#11: unexpected ECDSA signature length %d", len(sig))
+	}
+	half := len(sig) / 2
+	r := new(big.Int).SetBytes(sig[:half])
+	s := new(big.Int).SetBytes(sig[half:])
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// -D에서 사용하는 최소 SOCKS5 구현: 인증 없음, CONNECT 명령만 지원한다.
+const (
+	socks5Version    = 0x05
+	socks5NoAuth     = 0x00
+	socks5CmdConnect = 0x01
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+	socks5ReplyOK    = 0x00
+	socks5ReplyFail  = 0x01
+)
+
+// serveSOCKS5는 ln에 들어오는 연결마다 SOCKS5 핸드셰이크를 수행하고,
+// CONNECT 대상을 client.Dial로 ssh 터널을 통해 연결한다.
+func serveSOCKS5(client *ssh.Client, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go handleSOCKS5Conn(client, conn)
+	}
+}
+
+func handleSOCKS5Conn(client *ssh.Client, conn net.Conn) {
+	defer conn.Close()
+
+	if err := socks5Handshake(conn); err != nil {
+		debugLog("socks5: handshake failed: %v", err)
+		return
+	}
+
+	target, err := socks5ReadRequest(conn)
+	if err != nil {
+		debugLog("socks5: request failed: %v", err)
+		return
+	}
+
+	remote, err := client.Dial("tcp", target)
+	if err != nil {
+		socks5WriteReply(conn, socks5ReplyFail)
+		debugLog("socks5: dial %s failed: %v", target, err)
+		return
+	}
+	defer remote.Close()
+
+	if err := socks5WriteReply(conn, socks5ReplyOK); err != nil {
+		return
+	}
+
+	pipe(conn, remote)
+}
+
+func socks5Handshake(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	// 인증 없음(0x00)만 지원한다.
+	_, err := conn.Write([]byte{socks5Version, socks5NoAuth})
+	return err
+}
+
+func socks5ReadRequest(conn net.Conn) (string, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	if header[1] != socks5CmdConnect {
+		return "", fmt.Errorf("unsupported SOCKS command %d (only CONNECT is supported)", header[1])
+	}
+
+	var host string
+	switch header[3] {
+	case socks5AddrIPv4:
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socks5AddrDomain:
+		lenBuf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, lenBuf); err != nil {
+			return "", err
+		}
+		domain := make([]byte, lenBuf[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	case socks5AddrIPv6:
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	default:
+		return "", fmt.Errorf("unsupported SOCKS address type %d", header[3])
+	}
+
+	portBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBuf); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(portBuf)
+
+	return net.JoinHostPort(host, fmt.Sprintf("%d", port)), nil
+}
+
+func socks5WriteReply(conn net.Conn, reply byte) error {
+	// BND.ADDR/BND.PORT는 실제로 쓰이지 않으므로 0.0.0.0:0으로 고정한다.
+	_, err := conn.Write([]byte{socks5Version, reply, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"golang.org/x/crypto/ssh"
+)
+
+// parseSSHConfig는 ssh(1)과 동일한 규칙으로 SSH 설정 파일을 해석한다.
+// kevinburke/ssh_config가 Include, 와일드카드 Host 블록의 병합 순서를 그대로
+// 구현하고 있으므로, 여기서는 별칭이 아닌(실제로 접속 가능한) Host 패턴을 모아
+// 각 호스트별로 필요한 키를 조회하기만 하면 된다.
+//
+// Match는 "Match host <pattern>"과 "Match all"만 지원한다 - 라이브러리가 이
+// 둘을 Host 패턴으로 취급해 평가하기 때문이다. "Match user/exec/final/
+// originalhost/canonical/..." 같은 나머지 기준은 평가할 방법이 없으므로,
+// sanitizeConfigTree가 그 블록 전체를 건너뛴다(절대 매치하지 않는 것으로
+// 취급) - ssh(1)과 동일한 조건부 해석을 흉내 내는 것이 아니다.
+func parseSSHConfig(path string) ([]SSHHost, error) {
+	sanitizedPath, err := sanitizeConfigTree(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &ssh_config.UserSettings{}
+	settings.ConfigFinder(func() string { return sanitizedPath })
+
+	names, err := collectHostNames(sanitizedPath, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	comments := hostComments(path)
+
+	var hosts []SSHHost
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		hosts = append(hosts, resolveHost(settings, name, comments[name]))
+	}
+
+	return hosts, nil
+}
+
+// sanitizeConfigTree는 path(및 그 Include 대상들)를 임시 디렉터리에 복사하면서,
+// kevinburke/ssh_config가 평가할 수 없는 Match 기준("host"/"all" 외의 전부)이
+// 들어간 블록을 통째로 주석 처리한다. 그런 블록이 하나만 있어도 라이브러리의
+// Decode가 통째로 에러를 내기 때문에(예: "Match user git" → "ssh_config:
+// unsupported Match criterion \"user\""), 흔한 실제 ~/.ssh/config를 가진
+// 사용자에게는 mc 자체가 먹통이 된다. 반환된 경로는 원본과 동일한 Host 구조를
+// 갖되 그 블록들만 빠진 사본이다.
+func sanitizeConfigTree(path string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "mc-sshconfig-")
+	if err != nil {
+		return "", err
+	}
+
+	sanitized, err := sanitizeConfigFile(path, tmpDir, make(map[string]string))
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+
+	sshConfigTempDirMu.Lock()
+	sshConfigTempDirs = append(sshConfigTempDirs, tmpDir)
+	sshConfigTempDirMu.Unlock()
+
+	return sanitized, nil
+}
+
+var (
+	sshConfigTempDirMu sync.Mutex
+	sshConfigTempDirs  []string
+)
+
+// CleanupSSHConfigTemp는 sanitizeConfigTree가 만든 임시 디렉터리를 모두
+// 지운다. 설정 값 조회(settings.GetStrict)는 sanitize된 파일이 디스크에
+// 남아 있는 동안만 가능하므로, 연결이 전부 끝난 뒤(main의 defer)에만
+// 호출해야 한다.
+func CleanupSSHConfigTemp() {
+	sshConfigTempDirMu.Lock()
+	dirs := sshConfigTempDirs
+	sshConfigTempDirs = nil
+	sshConfigTempDirMu.Unlock()
+
+	for _, dir := range dirs {
+		os.RemoveAll(dir)
+	}
+}
+
+// sanitizeConfigFile은 path 하나를 sanitize해 tmpDir 아래에 쓰고 그 경로를
+// 돌려준다. done은 절대경로 → sanitize된 경로 캐시로, 순환/중복 Include를
+// 한 번만 처리하게 막는다.
+func sanitizeConfigFile(path, tmpDir string, done map[string]string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if sanitized, ok := done[abs]; ok {
+		return sanitized, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sanitizedPath := filepath.Join(tmpDir, fmt.Sprintf("%d.conf", len(done)))
+	done[abs] = sanitizedPath
+
+	dir := filepath.Dir(path)
+	lines := strings.Split(string(raw), "\n")
+	skipping := false
+	for i, line := range lines {
+		keyword, rest := configLineFields(line)
+		switch strings.ToLower(keyword) {
+		case "":
+			continue
+		case "host":
+			skipping = false
+		case "match":
+			if matchCriterionSupported(rest) {
+				skipping = false
+			} else {
+				debugLog("sshconfig: skipping unsupported Match block (Match %s) in %s", rest, path)
+				skipping = true
+				lines[i] = "#" + line
+			}
+		case "include":
+			if skipping {
+				lines[i] = "#" + line
+				continue
+			}
+			resolved, err := sanitizeIncludeTargets(rest, dir, tmpDir, done)
+			if err != nil || resolved == "" {
+				if err != nil {
+					debugLog("sshconfig: %v", err)
+				}
+				lines[i] = "#" + line
+				continue
+			}
+			lines[i] = "Include " + resolved
+		default:
+			if skipping {
+				lines[i] = "#" + line
+			}
+		}
+	}
+
+	if err := os.WriteFile(sanitizedPath, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		return "", err
+	}
+	return sanitizedPath, nil
+}
+
+// sanitizeIncludeTargets는 "Include" 줄의 나머지 부분(glob 패턴들)을 펼쳐
+// 각 대상 파일도 재귀적으로 sanitize하고, 그 결과 경로들을 공백으로 이어
+// 돌려준다.
+func sanitizeIncludeTargets(rest, dir, tmpDir string, done map[string]string) (string, error) {
+	var resolved []string
+	for _, field := range strings.Fields(rest) {
+		pattern := expandPath(field)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("include %s: %v", pattern, err)
+		}
+		for _, match := range matches {
+			sanitized, err := sanitizeConfigFile(match, tmpDir, done)
+			if err != nil {
+				debugLog("sshconfig: failed to sanitize included file %s: %v", match, err)
+				continue
+			}
+			resolved = append(resolved, sanitized)
+		}
+	}
+	return strings.Join(resolved, " "), nil
+}
+
+// configLineFields는 설정 파일 한 줄에서 키워드와 나머지 값을 뽑아낸다.
+// "Key value"와 "Key=value", "Key = value"를 모두 허용하는 ssh_config(5)
+// 문법을 그대로 따른다.
+func configLineFields(line string) (keyword, rest string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", ""
+	}
+	trimmed = strings.Replace(trimmed, "=", " ", 1)
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+// matchCriterionSupported는 kevinburke/ssh_config가 실제로 평가할 수 있는
+// Match 기준인지 본다 - "all"과 "host"뿐이고, 그 외(user, exec, final,
+// originalhost, canonical, tagged, ...)는 전부 Decode 단계에서 에러가 난다.
+func matchCriterionSupported(rest string) bool {
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "all", "host":
+		return true
+	default:
+		return false
+	}
+}
+
+// collectHostNames는 path를 파싱해 와일드카드가 아닌 Host 별칭을 모으고,
+// Include 지시어를 만나면 ssh(1)과 동일하게 그 파일들도 재귀적으로 따라가
+// 거기서 정의된 호스트도 목록에 포함시킨다. visited는 순환 Include를 막는다.
+func collectHostNames(path string, visited map[string]bool) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if visited[abs] {
+		return nil, nil
+	}
+	visited[abs] = true
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ssh_config.Decode(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var names []string
+	for _, block := range cfg.Hosts {
+		for _, pattern := range block.Patterns {
+			name := pattern.String()
+			// 와일드카드(*, ?, !)가 들어간 패턴은 호스트 목록에 표시하지 않고,
+			// 다른 Host 블록의 기본값으로만 병합된다. 블록이 Match에서 왔는지는
+			// 따로 확인하지 않는다.
+			if strings.ContainsAny(name, "*?!") {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+
+	for _, pattern := range includePatterns(path) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			debugLog("Include %s: %v", pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			included, err := collectHostNames(match, visited)
+			if err != nil {
+				debugLog("Include %s: %v", match, err)
+				continue
+			}
+			names = append(names, included...)
+		}
+	}
+
+	return names, nil
+}
+
+var includeRe = regexp.MustCompile(`(?i)^Include\s+(.+)$`)
+
+// includePatterns는 path를 한 번 더 훑어 "Include" 줄을 찾고, 그 안의 각
+// 경로를 ssh_config(5)와 같은 규칙(~/확장, Include하는 파일 기준 상대 경로)으로
+// 절대 경로 glob 패턴으로 바꾼다.
+func includePatterns(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		matches := includeRe.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if matches == nil {
+			continue
+		}
+		for _, field := range strings.Fields(matches[1]) {
+			field = expandPath(field)
+			if !filepath.IsAbs(field) {
+				field = filepath.Join(dir, field)
+			}
+			patterns = append(patterns, field)
+		}
+	}
+	return patterns
+}
+
+var hostLineRe = regexp.MustCompile(`(?i)^Host\s+(\S+)\s*$`)
+
+// hostComments는 각 "Host <alias>" 줄 위에 (빈 줄만 사이에 두고) 있는 마지막 "# comment" 줄을 alias별로
+// 모아 반환한다. ssh_config 라이브러리는 Include/Match 평가에 집중하고 이런 주석은
+// 버리므로, 사람이 보기 위한 Comment 필드는 원본 파일을 한 번 더 훑어서 채운다.
+func hostComments(path string) map[string]string {
+	comments := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return comments
+	}
+	defer f.Close()
+
+	var lastComment string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#"):
+			lastComment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			continue
+		case line == "":
+			continue
+		}
+
+		if matches := hostLineRe.FindStringSubmatch(line); matches != nil {
+			if !strings.ContainsAny(matches[1], "*?!") {
+				comments[matches[1]] = lastComment
+			}
+		}
+		lastComment = ""
+	}
+
+	return comments
+}
+
+// resolveHost는 alias에 대해 ssh(1)과 동일한 lookup 순서(Host 블록 → Host * 기본값)로
+// 설정 값을 조회해 SSHHost를 구성한다.
+func resolveHost(settings *ssh_config.UserSettings, alias, comment string) SSHHost {
+	// GetStrict는 alias에 대해 설정 파일에 아무 값도 없으면 ssh_config가 아는
+	// OpenSSH 내장 기본값(예: IdentityFile=~/.ssh/identity)을 채워 돌려준다.
+	// 여기서는 "사용자가 실제로 설정한 값"만 SSHHost에 반영하고 싶으므로, 그
+	// 기본값과 같으면 설정되지 않은 것으로 취급해 빈 문자열로 되돌린다.
+	get := func(key string) string {
+		v, _ := settings.GetStrict(alias, key)
+		if v == ssh_config.Default(key) {
+			return ""
+		}
+		return v
+	}
+
+	port := get("Port")
+	if port == "" {
+		port = "22"
+	}
+
+	host := SSHHost{
+		Name:                     alias,
+		HostName:                 get("HostName"),
+		User:                     get("User"),
+		Port:                     port,
+		Comment:                  strings.TrimSpace(comment),
+		ProxyJump:                get("ProxyJump"),
+		ProxyCommand:             get("ProxyCommand"),
+		ConnectTimeout:           get("ConnectTimeout"),
+		ServerAliveInterval:      get("ServerAliveInterval"),
+		IdentitiesOnly:           strings.EqualFold(get("IdentitiesOnly"), "yes"),
+		StrictHostKeyChecking:    strings.ToLower(get("StrictHostKeyChecking")),
+		PreferredAuthentications: get("PreferredAuthentications"),
+		AuthenticationMethods:    get("AuthenticationMethods"),
+		PKCS11Provider:           expandPath(get("PKCS11Provider")),
+	}
+
+	if identity := get("IdentityFile"); identity != "" {
+		host.IdentityFile = expandPath(identity)
+	}
+	if knownHosts := get("UserKnownHostsFile"); knownHosts != "" {
+		host.UserKnownHostsFile = expandPath(strings.Fields(knownHosts)[0])
+	}
+	if host.HostName == "" {
+		host.HostName = alias
+	}
+
+	return host
+}
+
+// dialClient는 host.ProxyJump이 비어 있으면 ProxyCommand가 있을 때 그 프로세스를
+// 거쳐, 없을 때 곧바로 ssh.Dial로 연결한다. ProxyJump이
+// 설정된 경우에는 쉼표로 구분된 각 홉을 순서대로 거쳐 tcp 채널을 연결한 뒤
+// 마지막 홉에서 target 호스트로 핸드셰이크한다 (ssh -J와 동일한 동작).
+func dialClient(host SSHHost, addr string, config *ssh.ClientConfig, allHosts []SSHHost) (*ssh.Client, error) {
+	hops := parseProxyJump(host.ProxyJump)
+
+	if len(hops) == 0 && host.ProxyCommand != "" {
+		conn, err := dialProxyCommand(host)
+		if err != nil {
+			return nil, fmt.Errorf("proxycommand: %v", err)
+		}
+		ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to handshake with %s via proxycommand: %v", addr, err)
+		}
+		return ssh.NewClient(ncc, chans, reqs), nil
+	}
+
+	if len(hops) == 0 {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	var client *ssh.Client
+	for i, hop := range hops {
+		hopHost, hopAddr := resolveProxyJumpHop(hop, allHosts)
+
+		hopKeyCB, err := hostKeyCallback(hopHost)
+		if err != nil {
+			return nil, fmt.Errorf("proxyjump hop %d (%s): %v", i+1, hop, err)
+		}
+		hopConfig := &ssh.ClientConfig{
+			User:            hopHost.User,
+			Auth:            buildAuthMethods(hopHost),
+			HostKeyCallback: hopKeyCB,
+		}
+
+		var conn net.Conn
+		if client == nil {
+			conn, err = net.Dial("tcp", hopAddr)
+		} else {
+			conn, err = client.Dial("tcp", hopAddr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("proxyjump hop %d (%s): %v", i+1, hop, err)
+		}
+
+		ncc, chans, reqs, err := ssh.NewClientConn(conn, hopAddr, hopConfig)
+		if err != nil {
+			return nil, fmt.Errorf("proxyjump hop %d (%s): %v", i+1, hop, err)
+		}
+		client = ssh.NewClient(ncc, chans, reqs)
+		debugLog("ProxyJump hop %d connected via %s", i+1, hopAddr)
+	}
+
+	conn, err := client.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s through proxyjump: %v", addr, err)
+	}
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to handshake with %s through proxyjump: %v", addr, err)
+	}
+	return ssh.NewClient(ncc, chans, reqs), nil
+}
+
+// dialProxyCommand는 host.ProxyCommand를 ssh(1)과 같은 규칙(%h/%p/%r 치환 후
+// 셸로 실행)으로 실행하고, 그 표준입출력을 전송 계층으로 쓸 수 있도록 net.Conn으로
+// 감싼다. ProxyJump이 같이 설정된 경우에는 ProxyJump이 우선한다.
+func dialProxyCommand(host SSHHost) (net.Conn, error) {
+	hostname := host.HostName
+	if hostname == "" {
+		hostname = host.Name
+	}
+	command := strings.NewReplacer("%h", hostname, "%p", host.Port, "%r", host.User).Replace(host.ProxyCommand)
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	debugLog("ProxyCommand: running %q", command)
+	return &proxyCommandConn{stdin: stdin, stdout: stdout, cmd: cmd}, nil
+}
+
+// proxyCommandConn은 ProxyCommand로 띄운 프로세스의 표준입출력을 net.Conn으로
+// 감싸 ssh.NewClientConn에 그대로 넘길 수 있게 한다. 실제 소켓이 아니므로
+// 주소/데드라인 관련 메서드는 의미가 없어 아무 동작도 하지 않는다.
+type proxyCommandConn struct {
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (c *proxyCommandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *proxyCommandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+func (c *proxyCommandConn) Close() error {
+	c.stdin.Close()
+	c.stdout.Close()
+	return c.cmd.Wait()
+}
+
+func (c *proxyCommandConn) LocalAddr() net.Addr                { return proxyCommandAddr{} }
+func (c *proxyCommandConn) RemoteAddr() net.Addr               { return proxyCommandAddr{} }
+func (c *proxyCommandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *proxyCommandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *proxyCommandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type proxyCommandAddr struct{}
+
+func (proxyCommandAddr) Network() string { return "proxycommand" }
+func (proxyCommandAddr) String() string  { return "proxycommand" }
+
+// parseProxyJump은 "user@host1:2222,host2"처럼 쉼표로 구분된 ProxyJump 값을
+// 홉 목록으로 분리한다. "none"은 ProxyJump을 사용하지 않음을 뜻한다.
+func parseProxyJump(value string) []string {
+	if value == "" || strings.EqualFold(value, "none") {
+		return nil
+	}
+	var hops []string
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop != "" {
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// resolveProxyJumpHop은 "user@host:port" 형태의 홉 스펙을 파싱한다. 스펙이
+// ~/.ssh/config에 정의된 별칭과 일치하면 그 설정(IdentityFile 등)을 그대로
+// 재사용하고, 아니라면 스펙에서 직접 user/host/port를 뽑아낸다.
+func resolveProxyJumpHop(spec string, allHosts []SSHHost) (SSHHost, string) {
+	user, hostport := spec, ""
+	if idx := strings.Index(spec, "@"); idx != -1 {
+		user = spec[:idx]
+		hostport = spec[idx+1:]
+	} else {
+		hostport = spec
+		user = ""
+	}
+
+	hostName, port := hostport, "22"
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		hostName, port = h, p
+	}
+
+	for _, h := range allHosts {
+		if h.Name == hostName {
+			resolved := h
+			if user != "" {
+				resolved.User = user
+			}
+			if port != "22" {
+				resolved.Port = port
+			}
+			addr := fmt.Sprintf("%s:%s", resolved.HostName, resolved.Port)
+			return resolved, addr
+		}
+	}
+
+	hop := SSHHost{Name: hostName, HostName: hostName, Port: port, User: user}
+	return hop, fmt.Sprintf("%s:%s", hostName, port)
+}
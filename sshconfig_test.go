@@ -0,0 +1,376 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseSSHConfigWildcardDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config")
+
+	configContent := `Host *
+    User defaultuser
+    Port 2200
+
+Host web
+    HostName web.example.com
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v", err)
+	}
+
+	web := findHost(hosts, "web")
+	if web == nil {
+		t.Fatal("web host not found")
+	}
+	if web.User != "defaultuser" {
+		t.Errorf("User = %q, want %q (inherited from Host *)", web.User, "defaultuser")
+	}
+	if web.Port != "2200" {
+		t.Errorf("Port = %q, want %q (inherited from Host *)", web.Port, "2200")
+	}
+}
+
+func TestParseSSHConfigInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	includedPath := filepath.Join(tmpDir, "extra.conf")
+	configPath := filepath.Join(tmpDir, "config")
+
+	if err := os.WriteFile(includedPath, []byte("Host included\n    HostName included.example.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write included config: %v", err)
+	}
+
+	configContent := "Include " + includedPath + "\n\nHost main\n    HostName main.example.com\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v", err)
+	}
+
+	if findHost(hosts, "main") == nil {
+		t.Error("main host not found")
+	}
+
+	included := findHost(hosts, "included")
+	if included == nil {
+		t.Fatal("included host not found")
+	}
+	if included.HostName != "included.example.com" {
+		t.Errorf("included.HostName = %q, want %q", included.HostName, "included.example.com")
+	}
+}
+
+func TestParseSSHConfigIncludeGlob(t *testing.T) {
+	tmpDir := t.TempDir()
+	confDir := filepath.Join(tmpDir, "conf.d")
+	if err := os.Mkdir(confDir, 0755); err != nil {
+		t.Fatalf("failed to create conf.d: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(confDir, "a.conf"), []byte("Host globbed-a\n    HostName a.example.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write a.conf: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(confDir, "b.conf"), []byte("Host globbed-b\n    HostName b.example.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write b.conf: %v", err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config")
+	configContent := "Include conf.d/*.conf\n\nHost main\n    HostName main.example.com\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v", err)
+	}
+
+	if findHost(hosts, "main") == nil {
+		t.Error("main host not found")
+	}
+	if a := findHost(hosts, "globbed-a"); a == nil || a.HostName != "a.example.com" {
+		t.Errorf("globbed-a not resolved correctly: %+v", a)
+	}
+	if b := findHost(hosts, "globbed-b"); b == nil || b.HostName != "b.example.com" {
+		t.Errorf("globbed-b not resolved correctly: %+v", b)
+	}
+}
+
+// A Match criterion kevinburke/ssh_config cannot evaluate (anything but
+// "host"/"all") makes its Decode() fail outright, which used to take the
+// whole config - and therefore mc itself - down with it. parseSSHConfig must
+// skip just that block and keep loading the rest of the file.
+func TestParseSSHConfigUnsupportedMatchSkipped(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config")
+
+	configContent := `Host before
+    HostName before.example.com
+
+Match user someoneelse
+    HostName should-not-apply.example.com
+
+Host after
+    HostName after.example.com
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v, want nil (unsupported Match block should be skipped)", err)
+	}
+
+	if before := findHost(hosts, "before"); before == nil || before.HostName != "before.example.com" {
+		t.Errorf("before not resolved correctly: %+v", before)
+	}
+	if after := findHost(hosts, "after"); after == nil || after.HostName != "after.example.com" {
+		t.Errorf("after not resolved correctly: %+v", after)
+	}
+	if findHost(hosts, "someoneelse") != nil {
+		t.Error("Match criterion should not be treated as a host alias")
+	}
+}
+
+func TestParseSSHConfigMatchHostSupported(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config")
+
+	configContent := `Match host web
+    User matcheduser
+
+Host web
+    HostName web.example.com
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	hosts, err := parseSSHConfig(configPath)
+	if err != nil {
+		t.Fatalf("parseSSHConfig() error = %v", err)
+	}
+
+	web := findHost(hosts, "web")
+	if web == nil {
+		t.Fatal("web host not found")
+	}
+	if web.User != "matcheduser" {
+		t.Errorf("User = %q, want %q (from Match host web)", web.User, "matcheduser")
+	}
+}
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "empty", value: "", expected: nil},
+		{name: "none", value: "none", expected: nil},
+		{name: "single hop", value: "bastion", expected: []string{"bastion"}},
+		{name: "multi hop", value: "bastion1, user@bastion2:2222", expected: []string{"bastion1", "user@bastion2:2222"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseProxyJump(tt.value)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("parseProxyJump(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("parseProxyJump(%q)[%d] = %q, want %q", tt.value, i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+// TestDialClientProxyJumpChaining exercises the actual multi-hop path in
+// dialClient (net.Dial -> ssh handshake -> client.Dial("direct-tcpip") ->
+// ssh handshake), not just the string parsing helpers it's built on. It
+// spins up two real in-process ssh servers: "hop" relays direct-tcpip
+// channels to whatever address the client asks for, and "target" is the
+// final destination the client actually wants to reach.
+func TestDialClientProxyJumpChaining(t *testing.T) {
+	target := newTestSSHServer(t, nil)
+	defer target.Close()
+
+	hop := newTestSSHServer(t, target.addr)
+	defer hop.Close()
+
+	hopHost := SSHHost{
+		Name:                  "hop",
+		HostName:              hop.host,
+		Port:                  hop.port,
+		User:                  "test",
+		StrictHostKeyChecking: strictHostKeyCheckingNo,
+	}
+	targetHost := SSHHost{
+		Name:                  "target",
+		HostName:              target.host,
+		Port:                  target.port,
+		User:                  "test",
+		ProxyJump:             "hop",
+		StrictHostKeyChecking: strictHostKeyCheckingNo,
+	}
+
+	targetConfig := &ssh.ClientConfig{
+		User:            targetHost.User,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+
+	client, err := dialClient(targetHost, target.addr.String(), targetConfig, []SSHHost{hopHost})
+	if err != nil {
+		t.Fatalf("dialClient() error = %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		t.Errorf("SendRequest through the chained client failed: %v", err)
+	}
+}
+
+// testSSHServer is a minimal in-process ssh server used only to exercise
+// dialClient's connection plumbing. If relayTo is set, it forwards every
+// direct-tcpip channel it receives to that address instead of acting as a
+// final destination - standing in for an intermediate ProxyJump hop.
+type testSSHServer struct {
+	listener net.Listener
+	addr     *net.TCPAddr
+	host     string
+	port     string
+	relayTo  *net.TCPAddr
+}
+
+func newTestSSHServer(t *testing.T, relayTo *net.TCPAddr) *testSSHServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test ssh server: %v", err)
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &testSSHServer{
+		listener: listener,
+		addr:     addr,
+		host:     addr.IP.String(),
+		port:     fmt.Sprintf("%d", addr.Port),
+		relayTo:  relayTo,
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate test host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromSigner(priv)
+	if err != nil {
+		t.Fatalf("failed to wrap test host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	go s.serve(config)
+	return s
+}
+
+func (s *testSSHServer) serve(config *ssh.ServerConfig) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn, config)
+	}
+}
+
+func (s *testSSHServer) handleConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if s.relayTo == nil || newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "test server only relays direct-tcpip")
+			continue
+		}
+		go s.relay(newChannel)
+	}
+}
+
+// relay accepts a direct-tcpip channel and pipes it to s.relayTo, standing in
+// for the real destination a ProxyJump hop would forward to.
+func (s *testSSHServer) relay(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	dst, err := net.Dial("tcp", s.relayTo.String())
+	if err != nil {
+		channel.Close()
+		return
+	}
+
+	go func() {
+		io.Copy(dst, channel)
+		dst.Close()
+	}()
+	io.Copy(channel, dst)
+	channel.Close()
+}
+
+func (s *testSSHServer) Close() {
+	s.listener.Close()
+}
+
+func TestResolveProxyJumpHop(t *testing.T) {
+	allHosts := []SSHHost{
+		{Name: "bastion", HostName: "bastion.example.com", Port: "22", User: "jumpuser", IdentityFile: "/home/user/.ssh/bastion_key"},
+	}
+
+	t.Run("known alias", func(t *testing.T) {
+		host, addr := resolveProxyJumpHop("bastion", allHosts)
+		if addr != "bastion.example.com:22" {
+			t.Errorf("addr = %q, want %q", addr, "bastion.example.com:22")
+		}
+		if host.IdentityFile != "/home/user/.ssh/bastion_key" {
+			t.Errorf("IdentityFile = %q, want inherited from config", host.IdentityFile)
+		}
+	})
+
+	t.Run("raw user@host:port spec", func(t *testing.T) {
+		host, addr := resolveProxyJumpHop("root@10.0.0.1:2222", allHosts)
+		if addr != "10.0.0.1:2222" {
+			t.Errorf("addr = %q, want %q", addr, "10.0.0.1:2222")
+		}
+		if host.User != "root" {
+			t.Errorf("User = %q, want %q", host.User, "root")
+		}
+	})
+}